@@ -0,0 +1,184 @@
+package logrus_influxdb
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	influxdb "github.com/influxdb/influxdb/client"
+)
+
+// Supported Config.Protocol values.
+const (
+	ProtocolHTTP = "http" // deliver points over the InfluxDB HTTP write API (default)
+	ProtocolUDP  = "udp"  // deliver points fire-and-forget over UDP line protocol
+)
+
+// Config describes how to connect and write to one or more InfluxDB
+// endpoints.
+type Config struct {
+	// URLs lists one or more endpoints, picked from at random on every
+	// flush for cluster failover. Format depends on Protocol: HTTP
+	// endpoints are full URLs (e.g. "http://host:8086"); UDP endpoints are
+	// bare "host:port" pairs, since net.ResolveUDPAddr rejects a scheme.
+	URLs []string
+
+	Username string
+	Password string
+
+	Database        string
+	RetentionPolicy string
+	Precision       string // timestamp precision to write with over v1 HTTP, e.g. "s", "ms", "us", "ns". Defaults to "s". The UDP and v2 transports always write nanosecond timestamps
+	Measurement     string // measurement name to write to. Defaults to "logrus"
+	Tags            map[string]string
+
+	// FieldMapper controls how a logrus.Entry is turned into a point's
+	// measurement, tags and fields. Defaults to a *DefaultFieldMapper.
+	FieldMapper FieldMapper
+
+	Timeout   time.Duration
+	UserAgent string
+	TLSConfig *tls.Config
+
+	// Protocol selects the wire transport: ProtocolHTTP (default) or
+	// ProtocolUDP for fire-and-forget delivery. Ignored once Bucket, Org or
+	// Token is set, since InfluxDB 2.x only writes over HTTP.
+	Protocol string
+
+	// InfluxDB 2.x auth. If any of Bucket, Org or Token is set, the hook
+	// targets a 2.x server (bucket/org/token auth) instead of 1.x
+	// (database/username/password), using the first of URLs as the server
+	// address and creating Bucket on first sight if it doesn't exist.
+	Bucket string
+	Org    string
+	Token  string
+}
+
+// NewHookFromConfig creates a hook from a fully specified Config.
+func NewHookFromConfig(conf *Config) (*InfulxDBHook, error) {
+	if conf == nil {
+		return nil, errors.New("logrus_influxdb: Config must not be nil")
+	}
+
+	protocol := conf.Protocol
+	if protocol == "" {
+		protocol = ProtocolHTTP
+	}
+	v2 := conf.Bucket != "" || conf.Org != "" || conf.Token != ""
+
+	urls := conf.URLs
+	if len(urls) == 0 {
+		if protocol == ProtocolUDP && !v2 {
+			urls = []string{fmt.Sprintf("%s:%d", DefaultHost, DefaultPort)}
+		} else {
+			urls = []string{fmt.Sprintf("http://%s:%d", DefaultHost, DefaultPort)}
+		}
+	}
+
+	database := conf.Database
+	if v2 {
+		database = conf.Bucket // v2 configs identify their destination by bucket, not database
+	}
+	if database == "" {
+		database = DefaultDatabase
+	}
+
+	tags := conf.Tags
+	if tags == nil {
+		tags = map[string]string{}
+	}
+
+	timeout := conf.Timeout
+	if timeout == 0 {
+		timeout = 100 * time.Millisecond
+	}
+
+	hook := newHook(database, tags)
+	if conf.RetentionPolicy != "" {
+		hook.retentionPolicy = conf.RetentionPolicy
+	}
+	if conf.Precision != "" {
+		hook.precision = conf.Precision
+	}
+	if conf.Measurement != "" {
+		hook.measurement = conf.Measurement
+	}
+	if conf.FieldMapper != nil {
+		hook.fieldMapper = conf.FieldMapper
+	}
+
+	if v2 {
+		w, err := newV2Writer(urls[0], conf.Bucket, conf.Org, conf.Token, timeout, conf.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+		hook.writer = w
+	} else {
+		switch protocol {
+		case ProtocolUDP:
+			addrs, err := resolveUDPAddrs(urls)
+			if err != nil {
+				return nil, err
+			}
+			hook.writer = &v1Writer{udpAddrs: addrs, protocol: ProtocolUDP, created: map[string]struct{}{}}
+		default:
+			clients, err := newHTTPClients(urls, conf.Username, conf.Password, timeout, conf.UserAgent, conf.TLSConfig)
+			if err != nil {
+				return nil, err
+			}
+
+			// Try pinging InfluxDB to see if it's a valid connection
+			if _, _, err := clients[0].Ping(); err != nil {
+				return nil, err
+			}
+			hook.writer = &v1Writer{clients: clients, protocol: ProtocolHTTP, created: map[string]struct{}{}}
+		}
+	}
+
+	if err := hook.writer.Setup(database); err != nil {
+		return nil, err
+	}
+
+	hook.start()
+	return hook, nil
+}
+
+// newHTTPClients builds one influxdb.Client per URL.
+func newHTTPClients(urls []string, username, password string, timeout time.Duration, userAgent string, tlsConfig *tls.Config) ([]*influxdb.Client, error) {
+	clients := make([]*influxdb.Client, 0, len(urls))
+	for _, rawurl := range urls {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, err
+		}
+		client, err := influxdb.NewClient(influxdb.Config{
+			URL:       *u,
+			Username:  username,
+			Password:  password,
+			Timeout:   timeout,
+			UserAgent: userAgent,
+			TLSConfig: tlsConfig,
+		})
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+// resolveUDPAddrs resolves one *net.UDPAddr per URL (host:port pairs).
+func resolveUDPAddrs(urls []string) ([]*net.UDPAddr, error) {
+	addrs := make([]*net.UDPAddr, 0, len(urls))
+	for _, rawurl := range urls {
+		addr, err := net.ResolveUDPAddr("udp", rawurl)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
@@ -0,0 +1,14 @@
+package logrus_influxdb
+
+import "testing"
+
+// TestNewHookFromConfigUDPDefaultURL verifies that a zero-config UDP hook
+// builds its default URL as a bare "host:port" pair rather than an
+// "http://host:port" URL, which net.ResolveUDPAddr rejects.
+func TestNewHookFromConfigUDPDefaultURL(t *testing.T) {
+	hook, err := NewHookFromConfig(&Config{Protocol: ProtocolUDP})
+	if err != nil {
+		t.Fatalf("NewHookFromConfig() error = %v, want nil", err)
+	}
+	hook.Close()
+}
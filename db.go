@@ -0,0 +1,92 @@
+package logrus_influxdb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	influxdb "github.com/influxdb/influxdb/client"
+)
+
+// v1 returns the hook's writer as a *v1Writer, or an error if the hook is
+// configured against a backend (InfluxDB v2, currently) that has no
+// database/retention-policy concept to manage.
+func (hook *InfulxDBHook) v1() (*v1Writer, error) {
+	w, ok := hook.writer.(*v1Writer)
+	if !ok {
+		return nil, errors.New("logrus_influxdb: database management is only supported against an InfluxDB v1 backend")
+	}
+	return w, nil
+}
+
+// Query runs cmd, an arbitrary InfluxQL command, against the hook's default
+// database and returns its results.
+func (hook *InfulxDBHook) Query(cmd string) ([]influxdb.Result, error) {
+	w, err := hook.v1()
+	if err != nil {
+		return nil, err
+	}
+	return w.queryDB(hook.database, cmd)
+}
+
+// CreateDatabase creates a database named name, the same way autocreateDatabase
+// does the first time the hook sees a new destination.
+func (hook *InfulxDBHook) CreateDatabase(name string) error {
+	w, err := hook.v1()
+	if err != nil {
+		return err
+	}
+	_, err = w.queryDB(name, fmt.Sprintf("create database %s", name))
+	return err
+}
+
+// DropDatabase drops the database named name.
+func (hook *InfulxDBHook) DropDatabase(name string) error {
+	w, err := hook.v1()
+	if err != nil {
+		return err
+	}
+	_, err = w.queryDB(name, fmt.Sprintf("drop database %s", name))
+	return err
+}
+
+// ListDatabases returns the names of every database on the server.
+func (hook *InfulxDBHook) ListDatabases() ([]string, error) {
+	w, err := hook.v1()
+	if err != nil {
+		return nil, err
+	}
+	results, err := w.queryDB(hook.database, "SHOW DATABASES")
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 || len(results[0].Series) == 0 {
+		return nil, nil
+	}
+
+	var names []string
+	for _, value := range results[0].Series[0].Values {
+		for _, val := range value {
+			if name, ok := val.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// CreateRetentionPolicy creates a retention policy named name on database db,
+// retaining data for duration with replication copies, optionally making it
+// db's default retention policy.
+func (hook *InfulxDBHook) CreateRetentionPolicy(db, name string, duration time.Duration, replication int, isDefault bool) error {
+	w, err := hook.v1()
+	if err != nil {
+		return err
+	}
+	cmd := fmt.Sprintf("create retention policy %s on %s duration %dns replication %d", name, db, duration.Nanoseconds(), replication)
+	if isDefault {
+		cmd += " default"
+	}
+	_, err = w.queryDB(db, cmd)
+	return err
+}
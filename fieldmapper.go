@@ -0,0 +1,106 @@
+package logrus_influxdb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// FieldMapper turns a logrus.Entry into the pieces of an InfluxDB point:
+// which measurement to write to, which entry fields become tags vs fields,
+// and what timestamp precision to use. It's called once per Fire, so
+// implementations should be cheap and side-effect free.
+//
+// An empty measurement or precision falls back to the hook's configured
+// default, so a FieldMapper only needs to return the ones it cares about.
+type FieldMapper interface {
+	Map(entry *logrus.Entry) (measurement string, tags map[string]string, fields map[string]interface{}, precision string)
+}
+
+// MeasurementFunc computes a measurement name from a logrus.Entry, e.g. to
+// route entries to a per-level or per-logger measurement.
+type MeasurementFunc func(entry *logrus.Entry) string
+
+// DefaultFieldMapper is the FieldMapper new hooks are given unless a
+// different one is configured. It promotes TagKeys out of entry.Data into
+// tags, always tags the entry's level, and copies every other entry.Data
+// key except the FieldDatabase and FieldRetentionPolicy routing keys into
+// the point's Fields, reducing anything that isn't already a line-protocol
+// scalar to its string representation.
+type DefaultFieldMapper struct {
+	// TagKeys lists entry.Data keys that should be promoted to tags instead
+	// of fields. "logger" and "server_name" are always promoted if present,
+	// for compatibility with github.com/evalphobia/logrus_sentry.
+	TagKeys []string
+
+	// Measurement computes the measurement name for an entry. If nil, the
+	// hook's configured default measurement is used for every entry.
+	Measurement MeasurementFunc
+
+	// Precision is the timestamp precision written with every point, e.g.
+	// "s", "ms", "us", "ns". If empty, the hook's configured default is
+	// used.
+	Precision string
+}
+
+// defaultTagKeys are always promoted to tags, matching the logger/
+// server_name handling this package has carried since the Sentry adapter
+// it borrowed getField from.
+var defaultTagKeys = []string{"logger", "server_name"}
+
+// Map implements FieldMapper.
+func (m *DefaultFieldMapper) Map(entry *logrus.Entry) (string, map[string]string, map[string]interface{}, string) {
+	tagKeys := make(map[string]struct{}, len(defaultTagKeys)+len(m.TagKeys))
+	for _, key := range defaultTagKeys {
+		tagKeys[key] = struct{}{}
+	}
+	for _, key := range m.TagKeys {
+		tagKeys[key] = struct{}{}
+	}
+
+	tags := map[string]string{"level": entry.Level.String()}
+	fields := map[string]interface{}{"message": entry.Message}
+
+	for key, val := range entry.Data {
+		if key == FieldDatabase || key == FieldRetentionPolicy {
+			continue // routing fields, not log data
+		}
+		if _, ok := tagKeys[key]; ok {
+			if s, ok := val.(string); ok {
+				tags[key] = s
+				continue
+			}
+			// Not a string: fall through and keep it as a field instead of
+			// silently dropping it.
+		}
+		fields[key] = toFieldValue(val)
+	}
+
+	var measurement string
+	if m.Measurement != nil {
+		measurement = m.Measurement(entry)
+	}
+
+	return measurement, tags, fields, m.Precision
+}
+
+// toFieldValue reduces val to a type the InfluxDB line protocol can encode
+// as a field: bool, string, or a numeric type. Anything else (structs,
+// maps, slices, *http.Request, ...) is rendered with fmt's default
+// formatting instead of being silently dropped.
+func toFieldValue(val interface{}) interface{} {
+	switch val.(type) {
+	case bool, string,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		return val
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// tagEscaper escapes the characters InfluxDB line protocol treats specially
+// in measurements, tag keys and tag values: commas, equals signs and spaces.
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
@@ -0,0 +1,299 @@
+package logrus_influxdb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	influxdb "github.com/influxdb/influxdb/client"
+)
+
+// OverflowPolicy controls what Fire does with a new point when the hook's
+// internal queue has already reached MaxQueueDepth.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes Fire block until the background flush frees up
+	// space in the queue. This is the default.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued point to make room for
+	// the new one.
+	OverflowDropOldest
+	// OverflowDropNewest discards the point that was about to be queued,
+	// leaving the existing queue untouched.
+	OverflowDropNewest
+)
+
+const (
+	DefaultBatchSize     = 100             // default number of points written per flush
+	DefaultFlushInterval = 5 * time.Second // default time between scheduled flushes
+	DefaultMaxQueueDepth = 1000            // default maximum number of points held in memory
+	DefaultMaxRetries    = 3               // default number of times a failed write is retried
+	DefaultRetryInterval = time.Second     // default backoff between write retries
+)
+
+// newHook builds an InfulxDBHook with its defaults applied. The hook's
+// writer must be set by the caller before calling start.
+func newHook(database string, tags map[string]string) *InfulxDBHook {
+	return &InfulxDBHook{
+		database:        database,
+		retentionPolicy: "default",
+		precision:       "s",
+		measurement:     "logrus",
+		tags:            tags,
+		fieldMapper:     &DefaultFieldMapper{},
+		batchSize:       DefaultBatchSize,
+		flushInterval:   DefaultFlushInterval,
+		maxQueueDepth:   DefaultMaxQueueDepth,
+		maxRetries:      DefaultMaxRetries,
+		retryInterval:   DefaultRetryInterval,
+		overflowPolicy:  OverflowBlock,
+		notify:          make(chan struct{}, 1),
+		intervalChanged: make(chan struct{}, 1),
+		closeCh:         make(chan struct{}),
+	}
+}
+
+// start launches the background flush loop. It must be called exactly once,
+// after the hook's transport has been fully configured.
+func (hook *InfulxDBHook) start() {
+	hook.timer = time.NewTimer(hook.flushInterval)
+	hook.closeWg.Add(1)
+	go hook.loop()
+}
+
+// SetBatchSize sets how many points are written to InfluxDB per flush.
+func (hook *InfulxDBHook) SetBatchSize(size int) {
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	hook.batchSize = size
+}
+
+// SetFlushInterval sets how often queued points are flushed in the
+// background, regardless of how many have accumulated. The running
+// background loop picks up the new interval as soon as it next wakes.
+func (hook *InfulxDBHook) SetFlushInterval(interval time.Duration) {
+	hook.mu.Lock()
+	hook.flushInterval = interval
+	hook.mu.Unlock()
+
+	select {
+	case hook.intervalChanged <- struct{}{}:
+	default:
+	}
+}
+
+// SetMaxQueueDepth sets the maximum number of points the hook will hold in
+// memory before applying its OverflowPolicy.
+func (hook *InfulxDBHook) SetMaxQueueDepth(depth int) {
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	hook.maxQueueDepth = depth
+}
+
+// SetMaxRetries sets how many times a failed flush is retried before the
+// points in it are given up on.
+func (hook *InfulxDBHook) SetMaxRetries(retries int) {
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	hook.maxRetries = retries
+}
+
+// SetRetryInterval sets the backoff duration between write retries.
+func (hook *InfulxDBHook) SetRetryInterval(interval time.Duration) {
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	hook.retryInterval = interval
+}
+
+// SetOverflowPolicy sets what happens to new points once MaxQueueDepth has
+// been reached.
+func (hook *InfulxDBHook) SetOverflowPolicy(policy OverflowPolicy) {
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	hook.overflowPolicy = policy
+}
+
+// SetFieldMapper sets the FieldMapper used to turn each logrus.Entry into
+// the measurement, tags and fields of the point written to InfluxDB.
+func (hook *InfulxDBHook) SetFieldMapper(mapper FieldMapper) {
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	hook.fieldMapper = mapper
+}
+
+// SetDroppedEntryCallback registers a function that's called with the
+// logrus.Entry for every point dropped, whether because the OverflowPolicy
+// discarded it or because its write failed after exhausting MaxRetries.
+func (hook *InfulxDBHook) SetDroppedEntryCallback(onDrop func(entry *logrus.Entry)) {
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	hook.onDrop = onDrop
+}
+
+// enqueue adds a point bound for the given database/retention policy to the
+// queue, applying the configured OverflowPolicy if the queue is already full.
+func (hook *InfulxDBHook) enqueue(point influxdb.Point, database, retentionPolicy string, entry *logrus.Entry) {
+	hook.mu.Lock()
+	for len(hook.queue) >= hook.maxQueueDepth {
+		switch hook.overflowPolicy {
+		case OverflowDropNewest:
+			if hook.onDrop != nil {
+				hook.onDrop(entry)
+			}
+			hook.mu.Unlock()
+			return
+		case OverflowDropOldest:
+			if hook.onDrop != nil {
+				hook.onDrop(hook.queue[0].entry)
+			}
+			hook.queue = hook.queue[1:]
+		default: // OverflowBlock
+			hook.mu.Unlock()
+			time.Sleep(time.Millisecond)
+			hook.mu.Lock()
+		}
+	}
+	hook.queue = append(hook.queue, queuedPoint{point: point, database: database, retentionPolicy: retentionPolicy, entry: entry})
+	full := len(hook.queue) >= hook.batchSize
+	hook.mu.Unlock()
+
+	if full {
+		hook.signalFlush()
+	}
+}
+
+// signalFlush wakes up the background loop without blocking if it's busy.
+func (hook *InfulxDBHook) signalFlush() {
+	select {
+	case hook.notify <- struct{}{}:
+	default:
+	}
+}
+
+// loop runs in the background, flushing queued points on every tick of
+// FlushInterval or as soon as it's woken up by a full batch. hook.timer is
+// only ever touched here, so a changed FlushInterval can be applied with a
+// plain Reset instead of needing to synchronize with SetFlushInterval.
+func (hook *InfulxDBHook) loop() {
+	defer hook.closeWg.Done()
+	defer hook.timer.Stop()
+
+	for {
+		select {
+		case <-hook.timer.C:
+			hook.flush()
+			hook.timer.Reset(hook.currentFlushInterval())
+		case <-hook.intervalChanged:
+			if !hook.timer.Stop() {
+				<-hook.timer.C
+			}
+			hook.timer.Reset(hook.currentFlushInterval())
+		case <-hook.notify:
+			hook.flush()
+		case <-hook.closeCh:
+			hook.closeErr = hook.flush()
+			return
+		}
+	}
+}
+
+// currentFlushInterval returns the hook's configured FlushInterval.
+func (hook *InfulxDBHook) currentFlushInterval() time.Duration {
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	return hook.flushInterval
+}
+
+// flush writes whatever is currently queued to InfluxDB in batches of
+// BatchSize, retrying transient failures with a fixed backoff. It keeps
+// draining the queue even once a batch has failed, and returns the first
+// error encountered, if any; points in a failed batch are given up on and
+// reported via SetDroppedEntryCallback.
+func (hook *InfulxDBHook) flush() error {
+	var firstErr error
+	for {
+		hook.mu.Lock()
+		if len(hook.queue) == 0 {
+			hook.mu.Unlock()
+			return firstErr
+		}
+		n := hook.batchSize
+		if n > len(hook.queue) {
+			n = len(hook.queue)
+		}
+		batch := hook.queue[:n]
+		hook.queue = hook.queue[n:]
+		hook.mu.Unlock()
+
+		if err := hook.writeBatch(batch); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+}
+
+// encodeLine renders a point as a single InfluxDB line protocol line, used
+// by the UDP transport which has no client library to do it for us.
+func encodeLine(point influxdb.Point) string {
+	var b strings.Builder
+	b.WriteString(tagEscaper.Replace(point.Measurement))
+	for key, val := range point.Tags {
+		b.WriteString(",")
+		b.WriteString(tagEscaper.Replace(key))
+		b.WriteString("=")
+		b.WriteString(tagEscaper.Replace(val))
+	}
+	b.WriteString(" ")
+
+	first := true
+	for key, val := range point.Fields {
+		if !first {
+			b.WriteString(",")
+		}
+		first = false
+		b.WriteString(tagEscaper.Replace(key))
+		b.WriteString("=")
+		b.WriteString(encodeFieldValue(val))
+	}
+
+	b.WriteString(" ")
+	b.WriteString(strconv.FormatInt(point.Time.UnixNano(), 10))
+	return b.String()
+}
+
+// encodeFieldValue renders a single field value in line protocol syntax.
+func encodeFieldValue(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%di", v)
+	case float32, float64:
+		return fmt.Sprintf("%v", v)
+	default:
+		return strconv.Quote(fmt.Sprintf("%v", v))
+	}
+}
+
+// Flush blocks until every point currently queued has been written to
+// InfluxDB, or given up on. It returns the first error encountered, if any;
+// points in a batch that failed after exhausting MaxRetries are reported via
+// SetDroppedEntryCallback rather than retried further by this call. A batch
+// that the background loop has already taken off the queue when Flush is
+// called is not waited for, and may still be in flight when Flush returns.
+func (hook *InfulxDBHook) Flush() error {
+	return hook.flush()
+}
+
+// Close stops the background flush loop after draining any queued points,
+// returning the error (if any) from that final flush. The hook must not be
+// used to Fire any further entries after Close returns.
+func (hook *InfulxDBHook) Close() error {
+	close(hook.closeCh)
+	hook.closeWg.Wait()
+	return hook.closeErr
+}
@@ -0,0 +1,148 @@
+package logrus_influxdb
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	influxdb "github.com/influxdb/influxdb/client"
+)
+
+func TestEncodeLine(t *testing.T) {
+	ts := time.Unix(0, 1234567890).UTC()
+
+	cases := []struct {
+		name  string
+		point influxdb.Point
+		want  string
+	}{
+		{
+			name: "basic",
+			point: influxdb.Point{
+				Measurement: "logrus",
+				Tags:        map[string]string{"level": "info"},
+				Fields:      map[string]interface{}{"message": "hello"},
+				Time:        ts,
+			},
+			want: `logrus,level=info message="hello" 1234567890`,
+		},
+		{
+			name: "escapes measurement, tag and field keys/values",
+			point: influxdb.Point{
+				Measurement: "my measurement",
+				Tags:        map[string]string{"host name": "a,b=c"},
+				Fields:      map[string]interface{}{"field key": int64(1)},
+				Time:        ts,
+			},
+			want: `my\ measurement,host\ name=a\,b\=c field\ key=1i 1234567890`,
+		},
+		{
+			name: "float field value",
+			point: influxdb.Point{
+				Measurement: "m",
+				Tags:        map[string]string{},
+				Fields:      map[string]interface{}{"n": 1.5},
+				Time:        ts,
+			},
+			want: `m n=1.5 1234567890`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := encodeLine(tc.point); got != tc.want {
+				t.Errorf("encodeLine() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// fakeWriter is an in-memory writer used to exercise the hook's
+// batching/retry/flush concurrency without a real InfluxDB server. If
+// writeErr is set, every Write fails with it.
+type fakeWriter struct {
+	mu       sync.Mutex
+	calls    int
+	writeErr error
+}
+
+func (w *fakeWriter) Setup(destination string) error { return nil }
+
+func (w *fakeWriter) Write(destination, retentionPolicy string, points []influxdb.Point) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.writeErr != nil {
+		return w.writeErr
+	}
+	w.calls += len(points)
+	return nil
+}
+
+// TestFireFlushConcurrent exercises Fire, Flush and SetFlushInterval from
+// multiple goroutines at once, alongside the hook's own background loop.
+// Run with -race: it doesn't assert much on its own, but it's the
+// concurrency contract Flush and Set* document.
+func TestFireFlushConcurrent(t *testing.T) {
+	hook := newHook(DefaultDatabase, map[string]string{})
+	hook.writer = &fakeWriter{}
+	hook.start()
+	defer hook.Close()
+
+	entry := logrus.NewEntry(logrus.New())
+	entry.Message = "hi"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hook.Fire(entry)
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hook.Flush()
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		hook.SetFlushInterval(time.Millisecond)
+	}()
+	wg.Wait()
+
+	hook.Flush()
+}
+
+// TestFlushReportsDroppedWrites verifies that a point whose write exhausts
+// MaxRetries is reported via SetDroppedEntryCallback and makes Flush return
+// an error, instead of being silently discarded.
+func TestFlushReportsDroppedWrites(t *testing.T) {
+	writeErr := errors.New("write failed")
+	hook := newHook(DefaultDatabase, map[string]string{})
+	hook.writer = &fakeWriter{writeErr: writeErr}
+	hook.SetMaxRetries(0)
+	hook.start()
+	defer hook.Close()
+
+	var dropped int32
+	hook.SetDroppedEntryCallback(func(entry *logrus.Entry) {
+		atomic.AddInt32(&dropped, 1)
+	})
+
+	entry := logrus.NewEntry(logrus.New())
+	entry.Message = "hi"
+	hook.Fire(entry)
+
+	if err := hook.Flush(); err == nil {
+		t.Fatal("Flush() = nil, want an error from the failed write")
+	}
+	if n := atomic.LoadInt32(&dropped); n != 1 {
+		t.Fatalf("onDrop called %d times, want 1", n)
+	}
+}
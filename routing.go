@@ -0,0 +1,107 @@
+package logrus_influxdb
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	influxdb "github.com/influxdb/influxdb/client"
+)
+
+// Reserved logrus.Entry fields used to route a point to a database and
+// retention policy other than the hook's defaults, analogous to Telegraf's
+// database_tag feature.
+const (
+	FieldDatabase        = "influxdb_database"
+	FieldRetentionPolicy = "influxdb_rp"
+)
+
+// queuedPoint pairs a point with the database and retention policy it should
+// be written to, and the logrus.Entry it came from, so it can still be
+// reported via SetDroppedEntryCallback if its write is later given up on.
+type queuedPoint struct {
+	point           influxdb.Point
+	database        string
+	retentionPolicy string
+	entry           *logrus.Entry
+}
+
+// writeBatch groups a batch of queued points by destination database and
+// retention policy and writes each group independently, so that entries
+// routed to different databases don't end up in the wrong one. Points in a
+// group that fails after exhausting MaxRetries are reported to onDrop and
+// then given up on; writeBatch returns the first error seen, if any.
+func (hook *InfulxDBHook) writeBatch(batch []queuedPoint) error {
+	type destination struct {
+		database        string
+		retentionPolicy string
+	}
+
+	order := make([]destination, 0, len(batch))
+	groups := make(map[destination][]queuedPoint, len(batch))
+	for _, qp := range batch {
+		dest := destination{qp.database, qp.retentionPolicy}
+		if _, ok := groups[dest]; !ok {
+			order = append(order, dest)
+		}
+		groups[dest] = append(groups[dest], qp)
+	}
+
+	var firstErr error
+	for _, dest := range order {
+		group := groups[dest]
+		points := make([]influxdb.Point, len(group))
+		for i, qp := range group {
+			points[i] = qp.point
+		}
+
+		if err := hook.writeGroup(dest.database, dest.retentionPolicy, points); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			hook.dropGroup(group)
+		}
+	}
+	return firstErr
+}
+
+// dropGroup reports every point in group to the hook's DroppedEntryCallback,
+// if one is set, after its write has been given up on.
+func (hook *InfulxDBHook) dropGroup(group []queuedPoint) {
+	hook.mu.Lock()
+	onDrop := hook.onDrop
+	hook.mu.Unlock()
+	if onDrop == nil {
+		return
+	}
+	for _, qp := range group {
+		onDrop(qp.entry)
+	}
+}
+
+// writeGroup writes a single batch of points, all bound for the same
+// database and retention policy, retrying on failure up to MaxRetries times.
+// It defers to the hook's writer to auto-create the destination on first
+// sight (and again if a write comes back complaining it's missing); a
+// transient Setup failure is retried the same as a failed Write, so the
+// caller can treat any error returned here as "failed after exhausting
+// MaxRetries", not just write errors.
+func (hook *InfulxDBHook) writeGroup(database, retentionPolicy string, points []influxdb.Point) error {
+	hook.mu.Lock()
+	maxRetries, retryInterval := hook.maxRetries, hook.retryInterval
+	hook.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(retryInterval)
+		}
+
+		if err = hook.writer.Setup(database); err != nil {
+			continue
+		}
+		if err = hook.writer.Write(database, retentionPolicy, points); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,178 @@
+package logrus_influxdb
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+
+	influxdb "github.com/influxdb/influxdb/client"
+)
+
+// writer abstracts delivery of a batch of points to an InfluxDB backend, so
+// the hook's batching/retry/routing logic doesn't need to branch on which
+// InfluxDB version it's talking to.
+type writer interface {
+	// Setup prepares destination (a v1 database or a v2 bucket, depending on
+	// the implementation) to receive writes, auto-creating it the first
+	// time it's seen.
+	Setup(destination string) error
+	// Write delivers points to destination. retentionPolicy is honored by
+	// v1 backends over HTTP and ignored by v2, which has no retention-policy
+	// concept. The v1 UDP transport ignores both destination and
+	// retentionPolicy: the server's UDP listener decides where points go.
+	Write(destination, retentionPolicy string, points []influxdb.Point) error
+}
+
+// v1Writer writes points to an InfluxDB 1.x server (or cluster) over HTTP or
+// UDP, auto-creating destination databases the first time they're seen.
+type v1Writer struct {
+	clients  []*influxdb.Client
+	udpAddrs []*net.UDPAddr
+	protocol string
+
+	mu      sync.Mutex // guards created, since Flush can run concurrently with the background loop
+	created map[string]struct{}
+}
+
+// Setup implements writer.
+func (w *v1Writer) Setup(database string) error {
+	if w.protocol == ProtocolUDP {
+		return nil // no control plane over UDP
+	}
+
+	w.mu.Lock()
+	_, ok := w.created[database]
+	w.mu.Unlock()
+	if ok {
+		return nil
+	}
+
+	if err := w.autocreateDatabase(database); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.created[database] = struct{}{}
+	w.mu.Unlock()
+	return nil
+}
+
+// Write implements writer.
+func (w *v1Writer) Write(database, retentionPolicy string, points []influxdb.Point) error {
+	if w.protocol == ProtocolUDP {
+		return w.writeUDP(points)
+	}
+
+	_, err := w.pickClient().Write(influxdb.BatchPoints{
+		Points:          points,
+		Database:        database,
+		RetentionPolicy: retentionPolicy,
+	})
+	if err != nil && isDatabaseNotFoundErr(err) {
+		w.mu.Lock()
+		delete(w.created, database)
+		w.mu.Unlock()
+		w.Setup(database)
+	}
+	return err
+}
+
+// pickClient returns one of the configured HTTP clients, chosen at random
+// so that writes are distributed across a cluster.
+func (w *v1Writer) pickClient() *influxdb.Client {
+	if len(w.clients) == 1 {
+		return w.clients[0]
+	}
+	return w.clients[rand.Intn(len(w.clients))]
+}
+
+// pickUDPAddr returns one of the configured UDP endpoints, chosen at random.
+func (w *v1Writer) pickUDPAddr() *net.UDPAddr {
+	if len(w.udpAddrs) == 1 {
+		return w.udpAddrs[0]
+	}
+	return w.udpAddrs[rand.Intn(len(w.udpAddrs))]
+}
+
+// writeUDP sends a batch as newline-delimited line protocol to one of the
+// configured UDP endpoints, picked at random.
+func (w *v1Writer) writeUDP(points []influxdb.Point) error {
+	conn, err := net.DialUDP("udp", nil, w.pickUDPAddr())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	lines := make([]string, len(points))
+	for i, point := range points {
+		lines[i] = encodeLine(point)
+	}
+	_, err = conn.Write([]byte(strings.Join(lines, "\n")))
+	return err
+}
+
+// queryDB runs cmd against database and returns its results.
+func (w *v1Writer) queryDB(database, cmd string) ([]influxdb.Result, error) {
+	if len(w.clients) == 0 {
+		return nil, errors.New("logrus_influxdb: queries are not supported over the udp protocol")
+	}
+	response, err := w.pickClient().Query(influxdb.Query{
+		Command:  cmd,
+		Database: database,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.Error() != nil {
+		return nil, response.Error()
+	}
+	return response.Results, nil
+}
+
+// databaseExists returns back an error if the named database does not exist
+// in InfluxDB.
+func (w *v1Writer) databaseExists(database string) error {
+	results, err := w.queryDB(database, "SHOW DATABASES")
+	if err != nil {
+		return err
+	}
+	if results == nil || len(results) == 0 {
+		return errors.New("Missing results from InfluxDB query response")
+	}
+	if results[0].Series == nil || len(results[0].Series) == 0 {
+		return errors.New("Missing series from InfluxDB query response")
+	}
+	for _, value := range results[0].Series[0].Values {
+		for _, val := range value {
+			if v, ok := val.(string); ok { // InfluxDB returns back an interface. Try to check only the string values.
+				if v == database { // If we the database exists, return back nil errors
+					return nil
+				}
+			}
+		}
+	}
+	return errors.New("No database exists")
+}
+
+// autocreateDatabase tries to detect if the named database exists and if
+// not, automatically creates it.
+func (w *v1Writer) autocreateDatabase(database string) error {
+	err := w.databaseExists(database)
+	if err == nil {
+		return nil
+	}
+	_, err = w.queryDB(database, fmt.Sprintf("create database %s", database))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// isDatabaseNotFoundErr reports whether err looks like InfluxDB rejected a
+// write because the destination database doesn't exist.
+func isDatabaseNotFoundErr(err error) bool {
+	return strings.Contains(err.Error(), "database not found")
+}
@@ -0,0 +1,230 @@
+package logrus_influxdb
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	influxdb "github.com/influxdb/influxdb/client"
+)
+
+// v2Writer writes points to an InfluxDB 2.x server using bucket/org/token
+// auth, creating the destination bucket via the v2 API the first time it's
+// seen. InfluxDB 2.x has no query language shared with 1.x and no UDP write
+// path, so unlike v1Writer it only ever talks HTTP.
+type v2Writer struct {
+	baseURL string
+	org     string
+	token   string
+
+	httpClient *http.Client
+
+	mu      sync.Mutex // guards created and orgID, since Flush can run concurrently with the background loop
+	created map[string]struct{}
+	orgID   string // w.org's id, resolved lazily and cached on first bucket creation
+}
+
+// newV2Writer builds a v2Writer targeting the server at baseURL.
+func newV2Writer(baseURL, bucket, org, token string, timeout time.Duration, tlsConfig *tls.Config) (*v2Writer, error) {
+	if bucket == "" || org == "" || token == "" {
+		return nil, errors.New("logrus_influxdb: Bucket, Org and Token are all required for an InfluxDB v2 backend")
+	}
+	return &v2Writer{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		org:     org,
+		token:   token,
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		created: map[string]struct{}{},
+	}, nil
+}
+
+// Setup implements writer. It creates bucket if it doesn't already exist.
+func (w *v2Writer) Setup(bucket string) error {
+	w.mu.Lock()
+	_, ok := w.created[bucket]
+	w.mu.Unlock()
+	if ok {
+		return nil
+	}
+
+	exists, err := w.bucketExists(bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := w.createBucket(bucket); err != nil {
+			return err
+		}
+	}
+
+	w.mu.Lock()
+	w.created[bucket] = struct{}{}
+	w.mu.Unlock()
+	return nil
+}
+
+// Write implements writer. retentionPolicy is ignored: InfluxDB 2.x buckets
+// carry their own retention period, set at bucket-creation time.
+func (w *v2Writer) Write(bucket, retentionPolicy string, points []influxdb.Point) error {
+	lines := make([]string, len(points))
+	for i, point := range points {
+		lines[i] = encodeLine(point)
+	}
+
+	// encodeLine always writes a nanosecond Unix timestamp regardless of
+	// Point.Precision, so every v2 write must declare "ns" here to match,
+	// whatever precision the point was configured with.
+	req, err := w.newRequest("POST", "/api/v2/write", url.Values{
+		"org":       {w.org},
+		"bucket":    {bucket},
+		"precision": {"ns"},
+	}, strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("logrus_influxdb: v2 write to bucket %q failed with status %s", bucket, resp.Status)
+	}
+	return nil
+}
+
+// bucketExists reports whether bucket already exists in w.org.
+func (w *v2Writer) bucketExists(bucket string) (bool, error) {
+	req, err := w.newRequest("GET", "/api/v2/buckets", url.Values{
+		"org":  {w.org},
+		"name": {bucket},
+	}, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return false, fmt.Errorf("logrus_influxdb: v2 list buckets failed with status %s", resp.Status)
+	}
+
+	var listing struct {
+		Buckets []struct {
+			Name string `json:"name"`
+		} `json:"buckets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return false, err
+	}
+	for _, b := range listing.Buckets {
+		if b.Name == bucket {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// createBucket creates bucket in w.org.
+func (w *v2Writer) createBucket(bucket string) error {
+	orgID, err := w.resolveOrgID()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(struct {
+		Name  string `json:"name"`
+		OrgID string `json:"orgID"`
+	}{Name: bucket, OrgID: orgID})
+	if err != nil {
+		return err
+	}
+
+	req, err := w.newRequest("POST", "/api/v2/buckets", nil, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("logrus_influxdb: v2 create bucket %q failed with status %s", bucket, resp.Status)
+	}
+	return nil
+}
+
+// resolveOrgID resolves w.org (a name) to the organization ID InfluxDB 2.x's
+// bucket-create API requires, caching the result since an org's ID doesn't
+// change for the lifetime of the writer.
+func (w *v2Writer) resolveOrgID() (string, error) {
+	w.mu.Lock()
+	orgID := w.orgID
+	w.mu.Unlock()
+	if orgID != "" {
+		return orgID, nil
+	}
+
+	req, err := w.newRequest("GET", "/api/v2/orgs", url.Values{"org": {w.org}}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("logrus_influxdb: v2 resolve org %q failed with status %s", w.org, resp.Status)
+	}
+
+	var listing struct {
+		Orgs []struct {
+			ID string `json:"id"`
+		} `json:"orgs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return "", err
+	}
+	if len(listing.Orgs) == 0 {
+		return "", fmt.Errorf("logrus_influxdb: no InfluxDB v2 organization named %q", w.org)
+	}
+
+	w.mu.Lock()
+	w.orgID = listing.Orgs[0].ID
+	w.mu.Unlock()
+	return listing.Orgs[0].ID, nil
+}
+
+// newRequest builds an authenticated request against the v2 API.
+func (w *v2Writer) newRequest(method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := w.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Token "+w.token)
+	return req, nil
+}